@@ -0,0 +1,153 @@
+package oss
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	aliyunoss "github.com/aliyun/aliyun-oss-go-sdk/oss"
+
+	"github.com/vilamslep/backilli/pkg/fs/unit"
+)
+
+type OSSClient struct {
+	bucket     *aliyunoss.Bucket
+	bucketName string
+	root       string
+}
+
+func NewClient(conf unit.ClientConfig) (*OSSClient, error) {
+	client, err := aliyunoss.New(conf.Region, conf.KeyId, conf.KeySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := client.Bucket(conf.BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OSSClient{
+		bucket:     bucket,
+		bucketName: conf.BucketName,
+		root:       conf.Root,
+	}, nil
+}
+
+var _ unit.Client = (*OSSClient)(nil)
+
+func init() {
+	unit.Register("oss", func(conf unit.ClientConfig) (unit.Client, error) {
+		return NewClient(conf)
+	})
+}
+
+// objectKey builds the final object key for dst under root, normalizing
+// path separators the way Windows-originated backup paths arrive in.
+func (c *OSSClient) objectKey(dst string) string {
+	root := strings.TrimRight(c.root, `/\`)
+	s := strings.ReplaceAll(dst, `\`, "/")
+	return fmt.Sprintf("%s/%s", root, s)
+}
+
+// Read downloads the whole object at path into memory; prefer OpenRead for
+// large objects. versionId is accepted for parity with other backends but
+// ignored; this driver does not yet support versioning.
+func (c *OSSClient) Read(path string, versionId ...string) ([]byte, error) {
+	rc, err := c.OpenRead(path, versionId...)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return ioutil.ReadAll(rc)
+}
+
+// OpenRead opens a streaming read of the object at path without buffering
+// it into memory. versionId is accepted for parity with other backends but
+// ignored; this driver does not yet support versioning.
+func (c *OSSClient) OpenRead(path string, versionId ...string) (io.ReadCloser, error) {
+	return c.bucket.GetObject(path)
+}
+
+func (c *OSSClient) Write(src string, dst string) error {
+	return c.bucket.PutObjectFromFile(c.objectKey(dst), src)
+}
+
+// OpenWrite opens a streaming write to dst. The OSS SDK only exposes a
+// reader-based PutObject, so writes are bridged through an io.Pipe: bytes
+// written to the returned WriteCloser stream straight into the upload
+// without landing on disk, and Close waits for PutObject to finish and
+// surfaces its error. If PutObject fails or returns early, the pipe reader
+// is closed with that error too, so a Write already blocked waiting for OSS
+// to drain the pipe is unblocked instead of hanging forever.
+func (c *OSSClient) OpenWrite(dst string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := c.bucket.PutObject(c.objectKey(dst), pr)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &ossUploadWriter{PipeWriter: pw, done: done}, nil
+}
+
+// ossUploadWriter is the io.WriteCloser OpenWrite returns: Close closes the
+// pipe and then waits for the background PutObject to finish, so its error
+// (if any) reaches the caller.
+type ossUploadWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *ossUploadWriter) Close() error {
+	if err := w.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func (c *OSSClient) Ls(path string) ([]unit.File, error) {
+	var files []unit.File
+	marker := ""
+
+	for {
+		res, err := c.bucket.ListObjects(aliyunoss.Prefix(path), aliyunoss.Marker(marker))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range res.Objects {
+			parts := strings.Split(object.Key, "/")
+			name := parts[len(parts)-1]
+			if name == "" {
+				continue
+			}
+
+			files = append(files, unit.File{
+				Name: name,
+				Date: object.LastModified,
+			})
+		}
+
+		if !res.IsTruncated {
+			break
+		}
+		marker = res.NextMarker
+	}
+
+	return files, nil
+}
+
+// Remove deletes the object at path. versionId is accepted for parity with
+// other backends but ignored; this driver does not yet support versioning.
+func (c *OSSClient) Remove(path string, versionId ...string) error {
+	return c.bucket.DeleteObject(path)
+}
+
+func (c *OSSClient) Close() error {
+	return nil
+}
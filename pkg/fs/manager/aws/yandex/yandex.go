@@ -3,28 +3,76 @@ package yandex
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/vilamslep/backilli/pkg/fs"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	env "github.com/vilamslep/backilli/pkg/fs/environment"
 	"github.com/vilamslep/backilli/pkg/fs/unit"
+	"golang.org/x/sync/errgroup"
 )
 
 var ErrLoadingConfiguration = fmt.Errorf("failed to load cloud configuration")
 
-var limit int64 = 536870912
+var _ unit.Client = (*YandexClient)(nil)
+
+func init() {
+	unit.Register("yandex", func(conf unit.ClientConfig) (unit.Client, error) {
+		return NewClient(conf)
+	})
+}
+
+// defaultPartSize and defaultConcurrency are used when ClientConfig does not
+// override them; 512MB matches the chunk size the old temp-file splitter used.
+const (
+	defaultPartSize    int64 = 536870912
+	defaultConcurrency       = 4
+	maxPartRetries           = 3
+
+	// minPartSize is S3's own minimum for every part but the last one in a
+	// multipart upload; a smaller configured PartSize would only fail much
+	// later, at CompleteMultipartUpload.
+	minPartSize int64 = 5 * 1024 * 1024
+)
+
+// sha256MetadataKey is the object metadata key put stores the source file's
+// hex SHA-256 digest under, and Verify/VerifyOnRead read it back from.
+const sha256MetadataKey = "sha256"
 
 type YandexClient struct {
-	s3client   *s3.Client
-	bucketName string
-	cloudSep   string
-	cloudRoot  string
+	s3client    *s3.Client
+	bucketName  string
+	cloudSep    string
+	cloudRoot   string
+	partSize    int64
+	concurrency int
+	versions    bool
+
+	// verifyOnRead makes OpenRead hash the object as it streams and check it
+	// against sha256MetadataKey once the caller closes the stream.
+	verifyOnRead bool
+
+	// sseCustomerKey and sseKMSKeyId are mutually exclusive server-side
+	// encryption options; at most one is ever non-empty.
+	sseCustomerKey []byte
+	sseKMSKeyId    string
+
+	// envelopeEncryptor/envelopeDecryptor enable client-side AES-256
+	// envelope encryption; set independently so a write-only client doesn't
+	// need a private key and vice versa.
+	envelopeEncryptor *unit.EnvelopeEncryptor
+	envelopeDecryptor *unit.EnvelopeDecryptor
 }
 
 func NewClient(conf unit.ClientConfig) (*YandexClient, error) {
@@ -42,36 +90,184 @@ func NewClient(conf unit.ClientConfig) (*YandexClient, error) {
 
 	s3client := s3.NewFromConfig(cfg)
 
+	partSize := conf.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	} else if partSize < minPartSize {
+		return nil, fmt.Errorf("yandex: PartSize %d is below S3's %d byte minimum", partSize, minPartSize)
+	}
+
+	if len(conf.SSECustomerKey) > 0 && conf.SSEKMSKeyId != "" {
+		return nil, fmt.Errorf("yandex: SSECustomerKey and SSEKMSKeyId are mutually exclusive")
+	}
+
+	concurrency := conf.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	var envelopeEncryptor *unit.EnvelopeEncryptor
+	var envelopeDecryptor *unit.EnvelopeDecryptor
+
+	if conf.ClientSidePublicKey != "" {
+		pub, err := unit.ParseRSAPublicKeyPEM(conf.ClientSidePublicKey)
+		if err != nil {
+			return nil, err
+		}
+		envelopeEncryptor = &unit.EnvelopeEncryptor{PublicKey: pub}
+	}
+
+	if conf.ClientSidePrivateKey != "" {
+		priv, err := unit.ParseRSAPrivateKeyPEM(conf.ClientSidePrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		envelopeDecryptor = &unit.EnvelopeDecryptor{PrivateKey: priv}
+	}
+
 	return &YandexClient{
-		s3client:   s3client,
-		cloudRoot:  conf.Root,
-		cloudSep:   "/",
-		bucketName: conf.BucketName,
+		s3client:          s3client,
+		cloudRoot:         conf.Root,
+		cloudSep:          "/",
+		bucketName:        conf.BucketName,
+		partSize:          partSize,
+		concurrency:       concurrency,
+		versions:          conf.Versions,
+		verifyOnRead:      conf.VerifyOnRead,
+		sseCustomerKey:    conf.SSECustomerKey,
+		sseKMSKeyId:       conf.SSEKMSKeyId,
+		envelopeEncryptor: envelopeEncryptor,
+		envelopeDecryptor: envelopeDecryptor,
 	}, nil
 }
 
-func (c YandexClient) Read(path string) ([]byte, error) {
+// sseCustomerKeyMD5 computes the base64 MD5 digest S3 requires alongside a
+// raw SSE-C customer key on every request touching the encrypted object.
+func sseCustomerKeyMD5(key []byte) string {
+	sum := md5.Sum(key)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sha256Sums hashes fd's remaining contents from its current offset and
+// rewinds it back to the start, returning the digest as both hex (for
+// sha256MetadataKey) and base64 (for PutObjectInput.ChecksumSHA256).
+func sha256Sums(fd *os.File) (hexDigest string, b64Digest string, err error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, fd); err != nil {
+		return "", "", err
+	}
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+
+	sum := h.Sum(nil)
+	return hex.EncodeToString(sum), base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// Read downloads the whole object at path into memory; prefer OpenRead for
+// large objects. An optional versionId selects a historical version
+// instead of the current one.
+func (c YandexClient) Read(path string, versionId ...string) ([]byte, error) {
+	rc, err := c.OpenRead(path, versionId...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Close's error is kept: with verifyOnRead it carries a checksum mismatch.
+	data, err := ioutil.ReadAll(rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// OpenRead opens a streaming read of the object at path without buffering
+// it into memory. An optional versionId selects a historical version
+// instead of the current one.
+func (c YandexClient) OpenRead(path string, versionId ...string) (io.ReadCloser, error) {
 	object := &s3.GetObjectInput{
 		Bucket: aws.String(c.bucketName),
 		Key:    aws.String(path),
 	}
+	if v := firstVersionId(versionId); v != "" {
+		object.VersionId = aws.String(v)
+	}
+	if len(c.sseCustomerKey) > 0 {
+		object.SSECustomerAlgorithm = aws.String("AES256")
+		object.SSECustomerKey = aws.String(string(c.sseCustomerKey))
+		object.SSECustomerKeyMD5 = aws.String(sseCustomerKeyMD5(c.sseCustomerKey))
+	}
+
 	resp, err := c.s3client.GetObject(context.Background(), object)
 	if err != nil {
 		return nil, err
 	}
 
-	buf := make([]byte, int(resp.ContentLength))
-	defer resp.Body.Close()
-	var buffer bytes.Buffer
-	for true {
-		num, rerr := resp.Body.Read(buf)
-		if num > 0 {
-			buffer.Write(buf[:num])
-		} else if rerr == io.EOF || rerr != nil {
-			break
+	var rc io.ReadCloser = resp.Body
+
+	if c.envelopeDecryptor != nil {
+		wrappedKey, err := base64.StdEncoding.DecodeString(resp.Metadata[unit.EnvelopeKeyMetadataKey])
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		nonce, err := base64.StdEncoding.DecodeString(resp.Metadata[unit.EnvelopeNonceMetadataKey])
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+
+		plaintext, err := c.envelopeDecryptor.Decrypt(resp.Body, wrappedKey, nonce)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+
+		rc = readCloser{Reader: plaintext, Closer: resp.Body}
+	}
+
+	if c.verifyOnRead {
+		if want, ok := resp.Metadata[sha256MetadataKey]; ok {
+			rc = newVerifyingReadCloser(rc, want)
 		}
 	}
-	return buffer.Bytes(), nil
+
+	return rc, nil
+}
+
+// readCloser pairs a Reader with an unrelated Closer, so a derived reader
+// (e.g. a decrypting one) can still close the original resource it wraps.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// verifyingReadCloser hashes every byte read from rc and, once the caller
+// closes it, compares the digest against want, returning an error on
+// mismatch. A caller that closes before reading to EOF gets no guarantee.
+type verifyingReadCloser struct {
+	io.Reader
+	rc   io.ReadCloser
+	h    hash.Hash
+	want string
+}
+
+func newVerifyingReadCloser(rc io.ReadCloser, want string) *verifyingReadCloser {
+	h := sha256.New()
+	return &verifyingReadCloser{Reader: io.TeeReader(rc, h), rc: rc, h: h, want: want}
+}
+
+func (v *verifyingReadCloser) Close() error {
+	if err := v.rc.Close(); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(v.h.Sum(nil)); got != v.want {
+		return fmt.Errorf("yandex: checksum mismatch on read: stored %s, computed %s", v.want, got)
+	}
+	return nil
 }
 
 func (c YandexClient) Write(src string, dst string) error {
@@ -80,68 +276,295 @@ func (c YandexClient) Write(src string, dst string) error {
 		return err
 	}
 
-	if stat.Size()/limit > 0 {
-		return c.putSplitedFile(src, dst)
-	} else {
-		return c.putOnce(src, dst)
+	// Client-side envelope encryption seals chunks in order off a single
+	// running counter, which the concurrent, offset-addressed multipart
+	// path can't provide without per-part counter bookkeeping; route
+	// encrypted writes through putOnce regardless of size.
+	if c.envelopeEncryptor == nil && stat.Size() > c.partSize {
+		return c.putMultipart(src, dst, stat.Size())
 	}
+	return c.putOnce(src, dst)
 }
 
 func (c YandexClient) putOnce(src string, dst string) error {
 	return c.put(src, dst)
 }
 
-func (c YandexClient) putSplitedFile(src string, dst string) error {
-	buf := make([]byte, limit)
+// putMultipart uploads src as a single object at dst using the S3 multipart
+// upload API, streaming parts directly from src without writing temp files.
+// Parts are uploaded concurrently (bounded by c.concurrency); any part
+// failure aborts the whole upload. It hashes src in one sequential pass
+// before the concurrent part uploads start, so the object ends up with the
+// same sha256MetadataKey metadata putOnce stores, keeping Verify and
+// VerifyOnRead working regardless of which path wrote the object.
+func (c YandexClient) putMultipart(src string, dst string, size int64) error {
 	fd, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer fd.Close()
 
-	stopped := false
-	for i := 1; !stopped; i++ {
-		stopped, err = c.writeAndPutPartOfFiles(fd, buf, i, dst)
-		if err != nil {
-			return err
+	hexDigest, _, err := sha256Sums(fd)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	key := c.objectKey(dst)
+
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(c.bucketName),
+		Key:      aws.String(key),
+		Metadata: map[string]string{sha256MetadataKey: hexDigest},
+	}
+	if len(c.sseCustomerKey) > 0 {
+		createInput.SSECustomerAlgorithm = aws.String("AES256")
+		createInput.SSECustomerKey = aws.String(string(c.sseCustomerKey))
+		createInput.SSECustomerKeyMD5 = aws.String(sseCustomerKeyMD5(c.sseCustomerKey))
+	} else if c.sseKMSKeyId != "" {
+		createInput.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		createInput.SSEKMSKeyId = aws.String(c.sseKMSKeyId)
+	}
+
+	created, err := c.s3client.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return err
+	}
+	uploadId := aws.ToString(created.UploadId)
+
+	numParts := int(size / c.partSize)
+	if size%c.partSize != 0 {
+		numParts++
+	}
+
+	completed := make([]types.CompletedPart, numParts)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.concurrency)
+
+	for i := 0; i < numParts; i++ {
+		partNumber := int32(i + 1)
+		offset := int64(i) * c.partSize
+		partSize := c.partSize
+		if offset+partSize > size {
+			partSize = size - offset
 		}
+
+		g.Go(func() error {
+			etag, err := c.uploadPartWithRetry(gctx, fd, key, uploadId, partNumber, offset, partSize)
+			if err != nil {
+				return err
+			}
+			completed[partNumber-1] = types.CompletedPart{
+				ETag:       etag,
+				PartNumber: aws.Int32(partNumber),
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		c.abortMultipart(key, uploadId)
+		return err
+	}
+
+	if _, err := c.s3client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.bucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	}); err != nil {
+		c.abortMultipart(key, uploadId)
+		return err
 	}
 
 	return nil
 }
 
-func (c YandexClient) writeAndPutPartOfFiles(fd *os.File, buf []byte, part int, dst string) (bool, error) {
-	if n, err := fd.Read(buf); err != nil {
-		if err == io.EOF {
-			return true, nil
-		} else {
-			return true, err
+// uploadPartWithRetry uploads a single part read from fd at [offset, offset+size),
+// making up to maxPartRetries attempts in all. The part streams straight
+// from fd via io.SectionReader rather than being buffered into memory, so
+// concurrency parts in flight share the one fd instead of costing
+// partSize*concurrency of RAM; *os.File.ReadAt (which SectionReader uses under the hood) is safe
+// to call concurrently from multiple goroutines on the same fd.
+func (c YandexClient) uploadPartWithRetry(ctx context.Context, fd *os.File, key, uploadId string, partNumber int32, offset, size int64) (*string, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxPartRetries; attempt++ {
+		partInput := &s3.UploadPartInput{
+			Bucket:     aws.String(c.bucketName),
+			Key:        aws.String(key),
+			UploadId:   aws.String(uploadId),
+			PartNumber: aws.Int32(partNumber),
+			Body:       io.NewSectionReader(fd, offset, size),
 		}
-	} else if n == 0 {
-		return true, nil
+		if len(c.sseCustomerKey) > 0 {
+			partInput.SSECustomerAlgorithm = aws.String("AES256")
+			partInput.SSECustomerKey = aws.String(string(c.sseCustomerKey))
+			partInput.SSECustomerKeyMD5 = aws.String(sseCustomerKeyMD5(c.sseCustomerKey))
+		}
+
+		resp, err := c.s3client.UploadPart(ctx, partInput)
+		if err == nil {
+			return resp.ETag, nil
+		}
+		lastErr = err
 	}
-	//write temp file
-	var err error
 
-	fp := fs.GetFullPath("", os.TempDir(), fmt.Sprintf("zip.%03d", part))
-	if fd, err = os.Create(fp); err == nil {
-		if _, err := fd.Write(buf); err != nil {
-			return true, err
-		} else if err := fd.Close(); err != nil {
-			return true, err
+	return nil, lastErr
+}
+
+// OpenWrite opens a streaming write to dst, backed by a multipart upload
+// that buffers at most one part in memory at a time; the caller must Close
+// it to flush the final part and complete the upload. Client-side envelope
+// encryption isn't supported here yet, so it errors rather than silently
+// streaming plaintext to a caller that asked for encryption; use Write's
+// putOnce path for that instead.
+func (c YandexClient) OpenWrite(dst string) (io.WriteCloser, error) {
+	if c.envelopeEncryptor != nil {
+		return nil, fmt.Errorf("yandex: OpenWrite does not support client-side envelope encryption; use Write instead")
+	}
+
+	ctx := context.Background()
+	key := c.objectKey(dst)
+
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(key),
+	}
+	if len(c.sseCustomerKey) > 0 {
+		createInput.SSECustomerAlgorithm = aws.String("AES256")
+		createInput.SSECustomerKey = aws.String(string(c.sseCustomerKey))
+		createInput.SSECustomerKeyMD5 = aws.String(sseCustomerKeyMD5(c.sseCustomerKey))
+	} else if c.sseKMSKeyId != "" {
+		createInput.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		createInput.SSEKMSKeyId = aws.String(c.sseKMSKeyId)
+	}
+
+	created, err := c.s3client.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return nil, err
+	}
+
+	return &multipartWriter{
+		c:        c,
+		ctx:      ctx,
+		key:      key,
+		uploadId: aws.ToString(created.UploadId),
+		buf:      bytes.NewBuffer(make([]byte, 0, c.partSize)),
+	}, nil
+}
+
+// multipartWriter is the io.WriteCloser OpenWrite returns: it buffers
+// writes up to c.partSize, uploading a full buffer as the next part once
+// more bytes arrive (or on Close), and completes the multipart upload on
+// Close.
+type multipartWriter struct {
+	c        YandexClient
+	ctx      context.Context
+	key      string
+	uploadId string
+	buf      *bytes.Buffer
+	partNum  int32
+	parts    []types.CompletedPart
+}
+
+func (w *multipartWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		free := int(w.c.partSize) - w.buf.Len()
+		if free <= 0 {
+			if err := w.flushPart(false); err != nil {
+				return written, err
+			}
+			free = int(w.c.partSize)
 		}
-	} else {
-		return true, err
+
+		n := free
+		if n > len(p) {
+			n = len(p)
+		}
+
+		w.buf.Write(p[:n])
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// flushPart uploads the buffered bytes as the next part. force uploads
+// even an empty buffer, which Close needs for objects nothing was ever
+// written to, since S3 requires at least one part to complete an upload.
+func (w *multipartWriter) flushPart(force bool) error {
+	if w.buf.Len() == 0 && !force {
+		return nil
+	}
+
+	w.partNum++
+	partInput := &s3.UploadPartInput{
+		Bucket:     aws.String(w.c.bucketName),
+		Key:        aws.String(w.key),
+		UploadId:   aws.String(w.uploadId),
+		PartNumber: aws.Int32(w.partNum),
+		Body:       bytes.NewReader(w.buf.Bytes()),
+	}
+	if len(w.c.sseCustomerKey) > 0 {
+		partInput.SSECustomerAlgorithm = aws.String("AES256")
+		partInput.SSECustomerKey = aws.String(string(w.c.sseCustomerKey))
+		partInput.SSECustomerKeyMD5 = aws.String(sseCustomerKeyMD5(w.c.sseCustomerKey))
+	}
+
+	resp, err := w.c.s3client.UploadPart(w.ctx, partInput)
+	if err != nil {
+		return err
+	}
+
+	w.parts = append(w.parts, types.CompletedPart{
+		ETag:       resp.ETag,
+		PartNumber: aws.Int32(w.partNum),
+	})
+	w.buf.Reset()
+	return nil
+}
+
+func (w *multipartWriter) Close() error {
+	if err := w.flushPart(w.partNum == 0); err != nil {
+		w.c.abortMultipart(w.key, w.uploadId)
+		return err
 	}
-	//put file to bucket
-	if err := c.put(fp, fs.GetFullPath("", dst, fs.Base(fp))); err != nil {
-		return false, err
+
+	if _, err := w.c.s3client.CompleteMultipartUpload(w.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(w.c.bucketName),
+		Key:             aws.String(w.key),
+		UploadId:        aws.String(w.uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: w.parts},
+	}); err != nil {
+		w.c.abortMultipart(w.key, w.uploadId)
+		return err
 	}
-	//delete temp file
-	if err := os.Remove(fp); err != nil {
-		return true, err
+
+	return nil
+}
+
+func (c YandexClient) abortMultipart(key, uploadId string) {
+	c.s3client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+}
+
+// objectKey builds the final S3 key for dst under cloudRoot, normalizing
+// path separators the way Windows-originated backup paths arrive in.
+func (c YandexClient) objectKey(dst string) string {
+	cloudRoot := c.cloudRoot
+	if len(cloudRoot) > 0 && (cloudRoot[len(cloudRoot)-1] == 0x5c ||
+		cloudRoot[len(cloudRoot)-1] == 0x2f) {
+		cloudRoot = cloudRoot[:len(cloudRoot)-1]
 	}
-	return false, err
+
+	s := bytes.ReplaceAll([]byte(dst), []byte{0x5c}, []byte{0x2f})
+
+	return fmt.Sprintf("%s%s%s", cloudRoot, c.cloudSep, string(s))
 }
 
 func (c YandexClient) put(src string, dst string) error {
@@ -156,21 +579,39 @@ func (c YandexClient) put(src string, dst string) error {
 		return err
 	}
 
-	cloudRoot := c.cloudRoot
-	if cloudRoot[len(cloudRoot)-1] == 0x5c ||
-		cloudRoot[len(cloudRoot)-1] == 0x2f {
-		cloudRoot = cloudRoot[:len(cloudRoot)-1]
+	hexDigest, b64Digest, err := sha256Sums(fd)
+	if err != nil {
+		return err
 	}
 
-	s := bytes.ReplaceAll([]byte(dst), []byte{0x5c}, []byte{0x2f})
-
-	yapath := fmt.Sprintf("%s%s%s", cloudRoot, c.cloudSep, string(s))
-
 	object := &s3.PutObjectInput{
 		Bucket:        aws.String(c.bucketName),
-		Key:           aws.String(yapath),
+		Key:           aws.String(c.objectKey(dst)),
 		Body:          fd,
 		ContentLength: stat.Size(),
+		Metadata:      map[string]string{sha256MetadataKey: hexDigest},
+	}
+
+	c.applyServerSideEncryption(object)
+
+	if c.envelopeEncryptor != nil {
+		ciphertext, wrappedKey, nonce, err := c.envelopeEncryptor.Encrypt(fd)
+		if err != nil {
+			return err
+		}
+		object.Body = ciphertext
+		// The GCM framing makes the ciphertext larger than the plaintext (a
+		// length prefix + auth tag per chunk), so ContentLength must be
+		// recomputed here or PutObject declares a length shorter than what
+		// Body actually streams.
+		object.ContentLength = unit.EnvelopeCiphertextSize(stat.Size())
+		object.Metadata[unit.EnvelopeKeyMetadataKey] = base64.StdEncoding.EncodeToString(wrappedKey)
+		object.Metadata[unit.EnvelopeNonceMetadataKey] = base64.StdEncoding.EncodeToString(nonce)
+	} else {
+		// ChecksumSHA256 is S3's own transmitted-bytes integrity check, so it
+		// must match what Body actually streams; skip it when Body has been
+		// swapped for the envelope-encrypted ciphertext above.
+		object.ChecksumSHA256 = aws.String(b64Digest)
 	}
 
 	if _, err = c.s3client.PutObject(context.Background(), object); err != nil {
@@ -180,7 +621,24 @@ func (c YandexClient) put(src string, dst string) error {
 	}
 }
 
+// applyServerSideEncryption sets the SSE-C or SSE-KMS fields on object, if
+// configured. At most one of the two is ever set on a YandexClient.
+func (c YandexClient) applyServerSideEncryption(object *s3.PutObjectInput) {
+	if len(c.sseCustomerKey) > 0 {
+		object.SSECustomerAlgorithm = aws.String("AES256")
+		object.SSECustomerKey = aws.String(string(c.sseCustomerKey))
+		object.SSECustomerKeyMD5 = aws.String(sseCustomerKeyMD5(c.sseCustomerKey))
+	} else if c.sseKMSKeyId != "" {
+		object.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		object.SSEKMSKeyId = aws.String(c.sseKMSKeyId)
+	}
+}
+
 func (c YandexClient) Ls(path string) ([]unit.File, error) {
+	if c.versions {
+		return c.lsVersions(path)
+	}
+
 	var ls *s3.ListObjectsV2Output
 	var err error
 
@@ -209,11 +667,63 @@ func (c YandexClient) Ls(path string) ([]unit.File, error) {
 	return files, nil
 }
 
-func (c YandexClient) Remove(path string) error {
+// lsVersions lists every version of every object under path, mirroring
+// rclone's --s3-versions behaviour: historical versions are named
+// "<name>-v<timestamp>" so they sit alongside the current version.
+// ListObjectVersions only returns up to 1000 entries per call, so results
+// are paginated via KeyMarker/VersionIdMarker until IsTruncated is false.
+func (c YandexClient) lsVersions(path string) ([]unit.File, error) {
+	params := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(c.bucketName),
+		Prefix: aws.String(path),
+	}
+
+	var files []unit.File
+	for {
+		ls, err := c.s3client.ListObjectVersions(context.TODO(), params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range ls.Versions {
+			parts := strings.Split(*object.Key, "/")
+
+			name := parts[len(parts)-1]
+			if name == "" {
+				continue
+			}
+
+			if !aws.ToBool(object.IsLatest) {
+				name = fmt.Sprintf("%s-v%s", name, object.LastModified.Format("20060102-150405"))
+			}
+
+			files = append(files, unit.File{
+				Date:      *object.LastModified,
+				Name:      name,
+				VersionID: aws.ToString(object.VersionId),
+			})
+		}
+
+		if !aws.ToBool(ls.IsTruncated) {
+			break
+		}
+		params.KeyMarker = ls.NextKeyMarker
+		params.VersionIdMarker = ls.NextVersionIdMarker
+	}
+
+	return files, nil
+}
+
+// Remove deletes the object at path. An optional versionId deletes that
+// specific historical version instead of placing a delete marker.
+func (c YandexClient) Remove(path string, versionId ...string) error {
 	deleteParams := &s3.DeleteObjectInput{
 		Bucket: aws.String(c.bucketName),
 		Key:    aws.String(path),
 	}
+	if v := firstVersionId(versionId); v != "" {
+		deleteParams.VersionId = aws.String(v)
+	}
 
 	if _, err := c.s3client.DeleteObject(context.TODO(), deleteParams); err != nil {
 		return err
@@ -221,10 +731,86 @@ func (c YandexClient) Remove(path string) error {
 	return nil
 }
 
+// EnableVersioning turns on bucket versioning so historical versions start
+// being retained.
+func (c YandexClient) EnableVersioning() error {
+	_, err := c.s3client.PutBucketVersioning(context.Background(), &s3.PutBucketVersioningInput{
+		Bucket: aws.String(c.bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	return err
+}
+
+// VersioningStatus reports whether versioning is enabled on the bucket.
+func (c YandexClient) VersioningStatus() (types.BucketVersioningStatus, error) {
+	resp, err := c.s3client.GetBucketVersioning(context.Background(), &s3.GetBucketVersioningInput{
+		Bucket: aws.String(c.bucketName),
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// Verify downloads the object at path and recomputes its SHA-256, returning
+// an error if it doesn't match the digest put stored under sha256MetadataKey
+// at upload time. Unlike VerifyOnRead, this always re-downloads and checks
+// regardless of how the client is configured.
+func (c YandexClient) Verify(path string) error {
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(path),
+	}
+	if len(c.sseCustomerKey) > 0 {
+		headInput.SSECustomerAlgorithm = aws.String("AES256")
+		headInput.SSECustomerKey = aws.String(string(c.sseCustomerKey))
+		headInput.SSECustomerKeyMD5 = aws.String(sseCustomerKeyMD5(c.sseCustomerKey))
+	}
+
+	head, err := c.s3client.HeadObject(context.Background(), headInput)
+	if err != nil {
+		return err
+	}
+
+	want, ok := head.Metadata[sha256MetadataKey]
+	if !ok {
+		return fmt.Errorf("yandex: object %q has no stored sha256 checksum", path)
+	}
+
+	rc, err := c.OpenRead(path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("yandex: checksum mismatch for %q: stored %s, computed %s", path, want, got)
+	}
+
+	return nil
+}
+
 func (c YandexClient) Close() error {
 	return nil
 }
 
+// firstVersionId returns the first versionId argument, or "" if none was
+// given; Read/Remove accept it as a variadic parameter to keep version
+// targeting optional without breaking existing call sites.
+func firstVersionId(versionId []string) string {
+	if len(versionId) == 0 {
+		return ""
+	}
+	return versionId[0]
+}
+
 func yandexResolver(service string, region string, options ...interface{}) (aws.Endpoint, error) {
 	if service == s3.ServiceID && region == "ru-central1" {
 		return aws.Endpoint{
@@ -0,0 +1,138 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	"github.com/vilamslep/backilli/pkg/fs/unit"
+)
+
+type GCSClient struct {
+	client     *storage.Client
+	bucketName string
+	root       string
+}
+
+func NewClient(conf unit.ClientConfig) (*GCSClient, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSClient{
+		client:     client,
+		bucketName: conf.BucketName,
+		root:       conf.Root,
+	}, nil
+}
+
+var _ unit.Client = (*GCSClient)(nil)
+
+func init() {
+	unit.Register("gcs", func(conf unit.ClientConfig) (unit.Client, error) {
+		return NewClient(conf)
+	})
+}
+
+// objectName builds the final object name for dst under root, normalizing
+// path separators the way Windows-originated backup paths arrive in.
+func (c *GCSClient) objectName(dst string) string {
+	root := strings.TrimRight(c.root, `/\`)
+	s := strings.ReplaceAll(dst, `\`, "/")
+	return fmt.Sprintf("%s/%s", root, s)
+}
+
+// Read downloads the whole object at path into memory; prefer OpenRead for
+// large objects. versionId is accepted for parity with other backends but
+// ignored; this driver does not yet support versioning.
+func (c *GCSClient) Read(path string, versionId ...string) ([]byte, error) {
+	rc, err := c.OpenRead(path, versionId...)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return ioutil.ReadAll(rc)
+}
+
+// OpenRead opens a streaming read of the object at path without buffering
+// it into memory. versionId is accepted for parity with other backends but
+// ignored; this driver does not yet support versioning.
+func (c *GCSClient) OpenRead(path string, versionId ...string) (io.ReadCloser, error) {
+	return c.client.Bucket(c.bucketName).Object(path).NewReader(context.Background())
+}
+
+// Write uploads src to dst. storage.Writer chunks and resumes the upload
+// under the hood, so multi-GB backups survive transient network errors
+// without buffering the whole file in memory.
+func (c *GCSClient) Write(src string, dst string) error {
+	fd, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	w, err := c.OpenWrite(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, fd); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// OpenWrite opens a streaming, resumable write to dst; the caller must
+// Close it to flush and finalize the upload.
+func (c *GCSClient) OpenWrite(dst string) (io.WriteCloser, error) {
+	return c.client.Bucket(c.bucketName).Object(c.objectName(dst)).NewWriter(context.Background()), nil
+}
+
+func (c *GCSClient) Ls(path string) ([]unit.File, error) {
+	ctx := context.Background()
+	it := c.client.Bucket(c.bucketName).Objects(ctx, &storage.Query{Prefix: path})
+
+	var files []unit.File
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parts := strings.Split(attrs.Name, "/")
+		name := parts[len(parts)-1]
+		if name == "" {
+			continue
+		}
+
+		files = append(files, unit.File{
+			Name: name,
+			Date: attrs.Updated,
+		})
+	}
+
+	return files, nil
+}
+
+// Remove deletes the object at path. versionId is accepted for parity with
+// other backends but ignored; this driver does not yet support versioning.
+func (c *GCSClient) Remove(path string, versionId ...string) error {
+	return c.client.Bucket(c.bucketName).Object(path).Delete(context.Background())
+}
+
+func (c *GCSClient) Close() error {
+	return c.client.Close()
+}
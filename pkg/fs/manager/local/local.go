@@ -1,6 +1,7 @@
 package local
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,114 +11,267 @@ import (
 	"github.com/vilamslep/backilli/pkg/fs/unit"
 )
 
+// encSidecarSuffix marks the file that holds a backup's client-side
+// encrypted bytes alongside the plaintext name it logically represents.
+const encSidecarSuffix = ".enc"
+
 type LocalClient struct {
 	root string
+
+	// envelopeEncryptor/envelopeDecryptor enable client-side AES-256
+	// envelope encryption, writing/reading an encSidecarSuffix file instead
+	// of the plain one; set independently so a write-only client doesn't
+	// need a private key and vice versa.
+	envelopeEncryptor *unit.EnvelopeEncryptor
+	envelopeDecryptor *unit.EnvelopeDecryptor
 }
 
-func NewClient(conf unit.ClientConfig) LocalClient {
-	return LocalClient{
-		root: conf.Root,
+func NewClient(conf unit.ClientConfig) (LocalClient, error) {
+	var envelopeEncryptor *unit.EnvelopeEncryptor
+	var envelopeDecryptor *unit.EnvelopeDecryptor
+
+	if conf.ClientSidePublicKey != "" {
+		pub, err := unit.ParseRSAPublicKeyPEM(conf.ClientSidePublicKey)
+		if err != nil {
+			return LocalClient{}, err
+		}
+		envelopeEncryptor = &unit.EnvelopeEncryptor{PublicKey: pub}
+	}
+
+	if conf.ClientSidePrivateKey != "" {
+		priv, err := unit.ParseRSAPrivateKeyPEM(conf.ClientSidePrivateKey)
+		if err != nil {
+			return LocalClient{}, err
+		}
+		envelopeDecryptor = &unit.EnvelopeDecryptor{PrivateKey: priv}
 	}
+
+	return LocalClient{
+		root:              conf.Root,
+		envelopeEncryptor: envelopeEncryptor,
+		envelopeDecryptor: envelopeDecryptor,
+	}, nil
 }
 
-func (c LocalClient) Read(path string) ([]byte, error) {
-	fd, err := os.OpenFile(path, os.O_RDWR, os.ModePerm)
+var _ unit.Client = LocalClient{}
+
+func init() {
+	unit.Register("local", func(conf unit.ClientConfig) (unit.Client, error) {
+		return NewClient(conf)
+	})
+}
+
+// Read reads the whole file at path into memory; prefer OpenRead for large
+// files. versionId is accepted for parity with version-aware clients but
+// ignored, since the local filesystem has no versioning of its own.
+func (c LocalClient) Read(path string, versionId ...string) ([]byte, error) {
+	rc, err := c.OpenRead(path, versionId...)
 	if err != nil {
 		return nil, err
 	}
-	defer fd.Close()
+	defer rc.Close()
+
+	return ioutil.ReadAll(rc)
+}
 
-	stat, err := fd.Stat()
+// OpenRead opens path for streaming reads without buffering it into memory.
+// versionId is accepted for parity with version-aware clients but ignored.
+// If client-side encryption is configured, path's encSidecarSuffix file is
+// opened and decrypted instead.
+func (c LocalClient) OpenRead(path string, versionId ...string) (io.ReadCloser, error) {
+	if c.envelopeDecryptor != nil {
+		return c.openReadEncrypted(path)
+	}
+
+	return os.Open(path)
+}
+
+// openReadEncrypted opens path's encSidecarSuffix file, written by
+// writeEncrypted/openWriteEncrypted as [4-byte wrapped-key length][wrapped
+// key][12-byte nonce][ciphertext], and returns the decrypting reader.
+func (c LocalClient) openReadEncrypted(path string) (io.ReadCloser, error) {
+	fd, err := os.Open(path + encSidecarSuffix)
 	if err != nil {
 		return nil, err
 	}
 
-	res := make([]byte, stat.Size())
-	buffer := make([]byte, 2048)
+	var keyLen uint32
+	if err := binary.Read(fd, binary.BigEndian, &keyLen); err != nil {
+		fd.Close()
+		return nil, err
+	}
 
-	offs := 0
-	for {
-		n, err := fd.Read(buffer)
+	wrappedKey := make([]byte, keyLen)
+	if _, err := io.ReadFull(fd, wrappedKey); err != nil {
+		fd.Close()
+		return nil, err
+	}
 
-		if err == io.EOF {
-			break
-		}
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(fd, nonce); err != nil {
+		fd.Close()
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, err
-		}
-		for i := 0; i < n; i++ {
-			res[(offs + i)] = buffer[i]
-		}
-		offs += len(buffer)
+	plaintext, err := c.envelopeDecryptor.Decrypt(fd, wrappedKey, nonce)
+	if err != nil {
+		fd.Close()
+		return nil, err
 	}
-	return res, nil
+
+	return readCloser{Reader: plaintext, Closer: fd}, nil
+}
+
+// readCloser pairs a Reader with an unrelated Closer, so a derived reader
+// (e.g. a decrypting one) can still close the original file it wraps.
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
 
+// Write copies src to dst. If client-side encryption is configured, dst's
+// encSidecarSuffix file is written instead.
 func (c LocalClient) Write(src string, dst string) error {
-	_, err := os.Stat(c.root)
-	if err != nil {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(c.root, os.ModePerm); err != nil {
-				return err
-			}
-		} else {
+	if c.envelopeEncryptor != nil {
+		fpf, err := c.prepareWritePath(dst)
+		if err != nil {
 			return err
 		}
+		return c.writeEncrypted(src, fpf)
+	}
+
+	rd, err := os.OpenFile(src, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	w, err := c.OpenWrite(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, rd); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// prepareWritePath ensures root and dst's parent directory exist, removes
+// any existing file at the target path, and returns the target's full path.
+func (c LocalClient) prepareWritePath(dst string) (string, error) {
+	if _, err := os.Stat(c.root); err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if err := os.MkdirAll(c.root, os.ModePerm); err != nil {
+			return "", err
+		}
 	}
 
 	fpf := fs.GetFullPath("", c.root, dst)
 	fpd := fs.Dir(fpf)
-	_, err = os.Stat(fpd)
-	if err != nil {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(fpd, os.ModePerm); err != nil {
-				return err
-			}
-		} else {
-			return err
+	if _, err := os.Stat(fpd); err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if err := os.MkdirAll(fpd, os.ModePerm); err != nil {
+			return "", err
 		}
 	}
 
-	_, err = os.Stat(fpf)
-	if os.IsExist(err) {
-		if err := os.RemoveAll(fpf);err != nil {
-			return err
+	if _, err := os.Stat(fpf); err == nil {
+		if err := os.RemoveAll(fpf); err != nil {
+			return "", err
 		}
-	} 
+	}
 
-	fd, err := os.OpenFile(fpf, os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	return fpf, nil
+}
+
+// OpenWrite opens dst for streaming writes, creating parent directories as
+// needed; the caller must Close it to flush and release the file handle.
+// If client-side encryption is configured, the returned writer encrypts to
+// an encSidecarSuffix file instead.
+func (c LocalClient) OpenWrite(dst string) (io.WriteCloser, error) {
+	fpf, err := c.prepareWritePath(dst)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if c.envelopeEncryptor != nil {
+		return c.openWriteEncrypted(fpf)
 	}
-	defer fd.Close()
 
+	return os.OpenFile(fpf, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+}
+
+// openWriteEncrypted writes fpf's encSidecarSuffix file as [4-byte
+// wrapped-key length][wrapped key][12-byte nonce][ciphertext], returning a
+// writer that encrypts everything written to it into that ciphertext
+// section; readable back by openReadEncrypted.
+func (c LocalClient) openWriteEncrypted(fpf string) (io.WriteCloser, error) {
+	fd, err := os.OpenFile(fpf+encSidecarSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	w, wrappedKey, nonce, err := c.envelopeEncryptor.EncryptWriter(fd)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if err := binary.Write(fd, binary.BigEndian, uint32(len(wrappedKey))); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if _, err := fd.Write(wrappedKey); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if _, err := fd.Write(nonce); err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// writeEncrypted encrypts src with c.envelopeEncryptor and writes it to
+// fpf's encSidecarSuffix file as [4-byte wrapped-key length][wrapped
+// key][12-byte nonce][ciphertext], readable back by openReadEncrypted.
+func (c LocalClient) writeEncrypted(src string, fpf string) error {
 	rd, err := os.OpenFile(src, os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		return err
 	}
 	defer rd.Close()
 
-	var bufferOffset int64 = 4096
+	ciphertext, wrappedKey, nonce, err := c.envelopeEncryptor.Encrypt(rd)
+	if err != nil {
+		return err
+	}
 
-	buf := make([]byte, bufferOffset)
+	fd, err := os.OpenFile(fpf+encSidecarSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
 
-	for {
-		n, err := rd.Read(buf)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		if n > 0 {
-			if _, err := fd.Write(buf); err != nil {
-				return err
-			}
-			continue
-		}
+	if err := binary.Write(fd, binary.BigEndian, uint32(len(wrappedKey))); err != nil {
+		return err
+	}
+	if _, err := fd.Write(wrappedKey); err != nil {
+		return err
+	}
+	if _, err := fd.Write(nonce); err != nil {
+		return err
 	}
 
+	_, err = io.Copy(fd, ciphertext)
 	return err
 }
 
@@ -145,7 +299,10 @@ func (c LocalClient) Ls(path string) ([]unit.File, error) {
 	return res, nil
 }
 
-func (c LocalClient) Remove(path string) error {
+// Remove deletes path. versionId is accepted for parity with version-aware
+// clients but ignored, since the local filesystem has no versioning of its
+// own.
+func (c LocalClient) Remove(path string, versionId ...string) error {
 	return os.RemoveAll(path)
 }
 
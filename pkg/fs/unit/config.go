@@ -0,0 +1,48 @@
+package unit
+
+// ClientConfig describes how a storage client should be constructed and
+// how uploads to it should behave.
+type ClientConfig struct {
+	Type       string
+	Region     string
+	KeyId      string
+	KeySecret  string
+	BucketName string
+	Root       string
+
+	// PartSize and Concurrency control multipart uploads to S3-compatible
+	// backends. Zero values fall back to the driver's own defaults.
+	PartSize    int64
+	Concurrency int
+
+	// Versions makes Ls on S3-compatible backends return historical versions
+	// alongside current objects. It does not turn versioning on for the
+	// bucket (YandexClient.EnableVersioning does that); Read/Remove accept a
+	// version id to target a specific version whether or not it is set.
+	Versions bool
+
+	// SSECustomerKey enables SSE-C: the raw (not base64-encoded) 32-byte
+	// AES-256 customer key used to encrypt objects server-side. Mutually
+	// exclusive with SSEKMSKeyId and ClientSidePublicKey.
+	SSECustomerKey []byte
+
+	// SSEKMSKeyId enables SSE-KMS using this KMS key id/ARN. Mutually
+	// exclusive with SSECustomerKey and ClientSidePublicKey.
+	SSEKMSKeyId string
+
+	// ClientSidePublicKey and ClientSidePrivateKey are PEM-encoded RSA keys
+	// that enable client-side AES-256 envelope encryption: Write wraps the
+	// source stream with a fresh per-object data key, itself wrapped with
+	// ClientSidePublicKey, and Read needs ClientSidePrivateKey to unwrap it
+	// again. Applied uniformly across backends, including LocalClient, which
+	// writes a ".enc" sidecar. Mutually exclusive with the SSE-* options
+	// above.
+	ClientSidePublicKey  string
+	ClientSidePrivateKey string
+
+	// VerifyOnRead makes Read/OpenRead hash the object as it streams and
+	// compare the result against the sha256 checksum stored at upload time,
+	// returning an error once the stream is closed if they don't match.
+	// Currently only honored by S3-compatible backends.
+	VerifyOnRead bool
+}
@@ -0,0 +1,370 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+)
+
+// Metadata keys under which EnvelopeEncryptor stores the wrapped data key
+// and nonce for a given object, so EnvelopeDecryptor can recover them
+// without an external key-value store. Backends translate these into
+// whatever their native object/sidecar metadata mechanism is.
+const (
+	EnvelopeKeyMetadataKey   = "envelope-key"
+	EnvelopeNonceMetadataKey = "envelope-nonce"
+)
+
+// envelopeChunkSize is the amount of plaintext sealed into each AES-256-GCM
+// frame. Framing the stream into fixed-size authenticated chunks, each
+// prefixed with its sealed length, lets Encrypt/Decrypt work over an
+// io.Reader/io.Writer without ever buffering a whole object in memory,
+// while still authenticating every byte.
+const envelopeChunkSize = 64 * 1024
+
+// envelopeFrameOverhead is the per-chunk bytes Encrypt/EncryptWriter add on
+// top of the plaintext: a 4-byte big-endian length prefix plus the 16-byte
+// GCM authentication tag.
+const envelopeFrameOverhead = 4 + 16
+
+// EnvelopeCiphertextSize returns the exact size Encrypt/EncryptWriter will
+// produce for a plainSize-byte input, so a caller that must declare a
+// Content-Length before streaming the ciphertext (e.g. an S3 PutObject) can
+// do so without buffering it first to measure it.
+func EnvelopeCiphertextSize(plainSize int64) int64 {
+	if plainSize == 0 {
+		return 0
+	}
+
+	chunks := plainSize / envelopeChunkSize
+	if plainSize%envelopeChunkSize != 0 {
+		chunks++
+	}
+
+	return plainSize + chunks*envelopeFrameOverhead
+}
+
+// EnvelopeEncryptor performs client-side envelope encryption for backups:
+// each object gets a fresh random AES-256 data key (DEK), the payload is
+// sealed chunk by chunk with AES-256-GCM keyed by the DEK, and the DEK
+// itself is wrapped with RSA-OAEP under PublicKey so only the holder of the
+// matching private key can recover it. GCM authenticates every chunk, so a
+// bit-flip against ciphertext at rest or in transit fails decryption instead
+// of silently producing attacker-controlled plaintext.
+type EnvelopeEncryptor struct {
+	PublicKey *rsa.PublicKey
+}
+
+// Encrypt wraps src in chunked AES-256-GCM framing keyed by a fresh
+// per-object DEK and returns the ciphertext stream along with the
+// RSA-OAEP-wrapped DEK and the base nonce, both of which must be stored
+// alongside the ciphertext for EnvelopeDecryptor to reverse it.
+func (e EnvelopeEncryptor) Encrypt(src io.Reader) (ciphertext io.Reader, wrappedKey []byte, nonce []byte, err error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, nil, nil, err
+	}
+
+	gcm, err := newEnvelopeGCM(dek)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, nil, err
+	}
+
+	wrappedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, e.PublicKey, dek, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &chunkEncryptReader{src: src, gcm: gcm, nonce: nonce}, wrappedKey, nonce, nil
+}
+
+// EncryptWriter is EnvelopeEncryptor's streaming-write counterpart to
+// Encrypt: it returns a WriteCloser that seals bytes written to it into
+// envelopeChunkSize GCM frames forwarded to dst, instead of wrapping an
+// existing reader. Close flushes the final, possibly short, frame and then
+// closes dst if dst implements io.Closer.
+func (e EnvelopeEncryptor) EncryptWriter(dst io.Writer) (ciphertext io.WriteCloser, wrappedKey []byte, nonce []byte, err error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, nil, nil, err
+	}
+
+	gcm, err := newEnvelopeGCM(dek)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, nil, err
+	}
+
+	wrappedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, e.PublicKey, dek, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &chunkEncryptWriter{dst: dst, gcm: gcm, nonce: nonce}, wrappedKey, nonce, nil
+}
+
+// EnvelopeDecryptor reverses EnvelopeEncryptor given the matching RSA
+// private key.
+type EnvelopeDecryptor struct {
+	PrivateKey *rsa.PrivateKey
+}
+
+// Decrypt returns a reader over the plaintext of src, given the wrapped DEK
+// and base nonce EnvelopeEncryptor produced for it. Each GCM frame is
+// authenticated as it's read; a tampered frame fails Read with an error
+// instead of yielding corrupt plaintext.
+func (d EnvelopeDecryptor) Decrypt(src io.Reader, wrappedKey, nonce []byte) (io.Reader, error) {
+	dek, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, d.PrivateKey, wrappedKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newEnvelopeGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunkDecryptReader{src: src, gcm: gcm, nonce: nonce}, nil
+}
+
+// newEnvelopeGCM builds the AES-256-GCM AEAD used to seal/open envelope
+// chunks under key.
+func newEnvelopeGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce derives the per-chunk GCM nonce from base by XORing a
+// big-endian chunk counter into its last 8 bytes, the same construction TLS
+// 1.3 uses for record nonces: unique per (key, counter) without needing a
+// fresh random nonce per chunk.
+func chunkNonce(base []byte, counter uint64) []byte {
+	n := make([]byte, len(base))
+	copy(n, base)
+
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], counter)
+	for i := 0; i < len(ctr); i++ {
+		n[len(n)-len(ctr)+i] ^= ctr[i]
+	}
+
+	return n
+}
+
+// chunkEncryptReader reads envelopeChunkSize plaintext pieces from src,
+// seals each with AES-256-GCM, and serves [4-byte big-endian sealed
+// length][sealed chunk] frames to its caller.
+type chunkEncryptReader struct {
+	src     io.Reader
+	gcm     cipher.AEAD
+	nonce   []byte
+	counter uint64
+	plain   []byte
+	out     bytes.Buffer
+	err     error
+}
+
+func (r *chunkEncryptReader) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		if r.plain == nil {
+			r.plain = make([]byte, envelopeChunkSize)
+		}
+
+		n, err := io.ReadFull(r.src, r.plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			r.err = err
+			continue
+		}
+
+		if n > 0 {
+			sealed := r.gcm.Seal(nil, chunkNonce(r.nonce, r.counter), r.plain[:n], nil)
+			r.counter++
+
+			var lenPrefix [4]byte
+			binary.BigEndian.PutUint32(lenPrefix[:], uint32(len(sealed)))
+			r.out.Write(lenPrefix[:])
+			r.out.Write(sealed)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			r.err = io.EOF
+		}
+	}
+
+	return r.out.Read(p)
+}
+
+// chunkEncryptWriter buffers writes up to envelopeChunkSize, sealing and
+// forwarding each full chunk as an AES-256-GCM frame to dst. Close flushes
+// whatever remains buffered as the final, possibly short, frame; an empty
+// buffer writes no frame.
+type chunkEncryptWriter struct {
+	dst     io.Writer
+	gcm     cipher.AEAD
+	nonce   []byte
+	counter uint64
+	buf     bytes.Buffer
+}
+
+func (w *chunkEncryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		free := envelopeChunkSize - w.buf.Len()
+		n := free
+		if n > len(p) {
+			n = len(p)
+		}
+
+		w.buf.Write(p[:n])
+		p = p[n:]
+		written += n
+
+		if w.buf.Len() == envelopeChunkSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush seals whatever is currently buffered as the next frame. Write only
+// triggers it once the buffer is full; Close calls it unconditionally to
+// seal the final, possibly short, chunk.
+func (w *chunkEncryptWriter) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	sealed := w.gcm.Seal(nil, chunkNonce(w.nonce, w.counter), w.buf.Bytes(), nil)
+	w.counter++
+
+	var lenPrefix [4]byte
+	binary.BigEndian.PutUint32(lenPrefix[:], uint32(len(sealed)))
+	if _, err := w.dst.Write(lenPrefix[:]); err != nil {
+		return err
+	}
+	if _, err := w.dst.Write(sealed); err != nil {
+		return err
+	}
+
+	w.buf.Reset()
+	return nil
+}
+
+// Close flushes the final chunk and, like cipher.StreamWriter, closes dst if
+// it implements io.Closer.
+func (w *chunkEncryptWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	if closer, ok := w.dst.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// chunkDecryptReader reverses chunkEncryptReader/chunkEncryptWriter: it reads
+// [4-byte big-endian sealed length][sealed chunk] frames from src, opens
+// each with AES-256-GCM, and serves the resulting plaintext. A frame that
+// fails authentication fails Read instead of yielding corrupt plaintext.
+type chunkDecryptReader struct {
+	src     io.Reader
+	gcm     cipher.AEAD
+	nonce   []byte
+	counter uint64
+	out     bytes.Buffer
+	err     error
+}
+
+func (r *chunkDecryptReader) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		var lenPrefix [4]byte
+		if _, err := io.ReadFull(r.src, lenPrefix[:]); err != nil {
+			if err == io.EOF {
+				r.err = io.EOF
+			} else {
+				r.err = err
+			}
+			continue
+		}
+
+		sealed := make([]byte, binary.BigEndian.Uint32(lenPrefix[:]))
+		if _, err := io.ReadFull(r.src, sealed); err != nil {
+			r.err = err
+			continue
+		}
+
+		plain, err := r.gcm.Open(nil, chunkNonce(r.nonce, r.counter), sealed, nil)
+		if err != nil {
+			r.err = fmt.Errorf("unit: envelope chunk %d failed authentication: %w", r.counter, err)
+			continue
+		}
+		r.counter++
+
+		r.out.Write(plain)
+	}
+
+	return r.out.Read(p)
+}
+
+// ParseRSAPublicKeyPEM parses a PEM-encoded PKIX RSA public key, as found in
+// ClientConfig.ClientSidePublicKey.
+func ParseRSAPublicKeyPEM(pemKey string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("unit: failed to decode PEM block containing public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unit: PEM block does not contain an RSA public key")
+	}
+
+	return pub, nil
+}
+
+// ParseRSAPrivateKeyPEM parses a PEM-encoded PKCS#1 RSA private key, as
+// found in ClientConfig.ClientSidePrivateKey.
+func ParseRSAPrivateKeyPEM(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("unit: failed to decode PEM block containing private key")
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
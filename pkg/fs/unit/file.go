@@ -16,6 +16,10 @@ type File struct {
 	Descriptor FileDescriptor
 	Name string
 	Date time.Time
+	// VersionID identifies a specific historical version of the file on
+	// backends that support versioning. A version-aware listing sets it on
+	// every entry, the current version included; it is empty otherwise.
+	VersionID string
 }
 
 func (f File) Close() error {
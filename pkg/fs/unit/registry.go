@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"fmt"
+	"io"
+)
+
+// Client is the interface every storage backend driver implements, so
+// backends can be swapped by nothing more than ClientConfig.Type.
+type Client interface {
+	Read(path string, versionId ...string) ([]byte, error)
+	Write(src string, dst string) error
+
+	// OpenRead and OpenWrite stream instead of buffering the whole object
+	// in memory; Read is a thin wrapper around OpenRead for callers that
+	// just want a []byte, while Write uploads a local file given its path.
+	OpenRead(path string, versionId ...string) (io.ReadCloser, error)
+	OpenWrite(dst string) (io.WriteCloser, error)
+
+	Ls(path string) ([]File, error)
+	Remove(path string, versionId ...string) error
+	Close() error
+}
+
+// Factory builds a Client from a ClientConfig. Drivers register one under
+// their ClientConfig.Type name via Register, typically from an init func.
+type Factory func(ClientConfig) (Client, error)
+
+var factories = make(map[string]Factory)
+
+// Register makes a driver available under name for NewClient to construct.
+// It is meant to be called from a driver package's init function and panics
+// on duplicate registration.
+func Register(name string, factory Factory) {
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("unit: driver %q already registered", name))
+	}
+	factories[name] = factory
+}
+
+// NewClient constructs the Client registered under conf.Type.
+func NewClient(conf ClientConfig) (Client, error) {
+	factory, ok := factories[conf.Type]
+	if !ok {
+		return nil, fmt.Errorf("unit: unknown client type %q", conf.Type)
+	}
+	return factory(conf)
+}